@@ -0,0 +1,169 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+// Command labeler-worker long-polls the label job queue populated by the products
+// service, runs Rekognition DetectLabels against each queued image, and writes the
+// resulting labels (and job status) back onto the product record.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/zxkane/retail-demo-store/src/products/src/products-service/labeler"
+)
+
+const (
+	maxMessages     = int64(10)
+	waitTimeSeconds = int64(20)
+
+	// maxLabelWriteRetries - retries for the optimistic-lock write in detectAndWriteLabels before
+	// giving up; covers the common case of racing a single other writer (manual curation
+	// or another enqueued job for the same product)
+	maxLabelWriteRetries = 3
+)
+
+var (
+	sess              = session.Must(session.NewSession())
+	sqsClient         = sqs.New(sess)
+	rekognitionClient = rekognition.New(sess)
+	dynamoClient      = dynamodb.New(sess)
+
+	queueURL = os.Getenv("LABELS_QUEUE_URL")
+	bucket   = os.Getenv("IMAGE_S3_BUCKET")
+	table    = os.Getenv("DDB_TABLE_PRODUCTS")
+)
+
+func main() {
+	if len(queueURL) == 0 {
+		log.Fatal("LABELS_QUEUE_URL must be set")
+	}
+
+	log.Println("labeler-worker started, polling", queueURL)
+
+	for {
+		output, err := sqsClient.ReceiveMessage(&sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: aws.Int64(maxMessages),
+			WaitTimeSeconds:     aws.Int64(waitTimeSeconds),
+		})
+		if err != nil {
+			log.Println("error receiving messages:", err)
+			continue
+		}
+
+		for _, message := range output.Messages {
+			processMessage(message)
+		}
+	}
+}
+
+func processMessage(message *sqs.Message) {
+	job, err := labeler.Decode(aws.StringValue(message.Body))
+	if err != nil {
+		log.Println("error decoding job, dropping message:", err)
+		deleteMessage(message)
+		return
+	}
+
+	if err := setLabelsStatus(job.ProductID, labeler.StatusPending); err != nil {
+		log.Println("error setting pending status for", job.ProductID, ":", err)
+	}
+
+	if err := detectAndWriteLabels(job); err != nil {
+		log.Println("error processing label job for", job.ProductID, ":", err)
+		if statusErr := setLabelsStatus(job.ProductID, labeler.StatusFailed); statusErr != nil {
+			log.Println("error setting failed status for", job.ProductID, ":", statusErr)
+		}
+		// Leave the message on the queue so it is retried (and eventually DLQ'd).
+		return
+	}
+
+	if err := setLabelsStatus(job.ProductID, labeler.StatusDone); err != nil {
+		log.Println("error setting done status for", job.ProductID, ":", err)
+	}
+
+	deleteMessage(message)
+}
+
+// detectAndWriteLabels - runs Rekognition on the queued image and merges the result into
+// the product's image_labels under labeler's optimistic lock, retrying on a lost race
+// against a concurrent manual curation request or another enqueued job for the same
+// product. Uses labeler.MergeAutoLabels, the same merge function the products service
+// calls, so the two can't drift apart.
+func detectAndWriteLabels(job labeler.Job) error {
+	result, err := rekognitionClient.DetectLabels(&rekognition.DetectLabelsInput{
+		Image: &rekognition.Image{
+			S3Object: &rekognition.S3Object{
+				Bucket: aws.String(bucket),
+				Name:   aws.String(job.S3Key),
+			},
+		},
+		MaxLabels: aws.Int64(10),
+	})
+	if err != nil {
+		return fmt.Errorf("calling DetectLabels: %w", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var detected []labeler.Label
+	for _, label := range result.Labels {
+		detected = append(detected, labeler.Label{
+			Name:       aws.StringValue(label.Name),
+			Confidence: aws.Float64Value(label.Confidence),
+			Source:     "rekognition",
+			UpdatedAt:  now,
+		})
+	}
+
+	for attempt := 0; attempt <= maxLabelWriteRetries; attempt++ {
+		existing, version, err := labeler.ReadLabels(dynamoClient, table, job.ProductID)
+		if err != nil {
+			return fmt.Errorf("reading existing labels: %w", err)
+		}
+
+		merged := labeler.MergeAutoLabels(existing, detected)
+
+		err = labeler.WriteLabels(dynamoClient, table, job.ProductID, merged, version)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, labeler.ErrVersionConflict) {
+			return fmt.Errorf("writing labels: %w", err)
+		}
+	}
+
+	return labeler.ErrVersionConflict
+}
+
+func setLabelsStatus(productID string, status string) error {
+	_, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(productID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(status)},
+		},
+		UpdateExpression: aws.String("set labels_status = :status"),
+	})
+	return err
+}
+
+func deleteMessage(message *sqs.Message) {
+	if _, err := sqsClient.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(queueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	}); err != nil {
+		log.Println("error deleting message:", err)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+//go:generate protoc --go_out=. --go-grpc_out=. --proto_path=proto proto/products.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	pb "github.com/zxkane/retail-demo-store/src/products/src/products-service/proto"
+)
+
+// grpcImageURLOption is a request-scoped flag threaded through the gRPC handlers so the
+// same fully-qualify-image-URL behavior as the HTTP transport is available without a
+// *http.Request to read a query param from.
+type grpcImageURLOption struct {
+	fullyQualify bool
+}
+
+// productServer implements pb.ProductServiceServer on top of the same Repo* functions
+// the HTTP handlers use, so both transports share one source of truth for the catalog.
+type productServer struct {
+	pb.UnimplementedProductServiceServer
+}
+
+func fullyQualifyProductImageURLGRPC(opt grpcImageURLOption, p *Product) {
+	if opt.fullyQualify {
+		if len(p.Image) > 0 && p.Image != missingImageFile {
+			p.Image = imageRootURL + p.Category + "/" + p.Image
+		} else {
+			p.Image = imageRootURL + missingImageFile
+		}
+	} else if len(p.Image) == 0 || p.Image == missingImageFile {
+		p.Image = missingImageFile
+	}
+}
+
+func toPbLabels(labels []ConfidenceLabel) []*pb.ImageLabel {
+	pbLabels := make([]*pb.ImageLabel, 0, len(labels))
+	for _, l := range labels {
+		pbLabels = append(pbLabels, &pb.ImageLabel{
+			Name:        l.Name,
+			Confidence:  l.Confidence,
+			Source:      l.Source,
+			Uncertainty: l.Uncertainty,
+			UpdatedAt:   l.UpdatedAt,
+		})
+	}
+	return pbLabels
+}
+
+func toPbProduct(p Product) *pb.Product {
+	return &pb.Product{
+		Id:           p.ID,
+		Name:         p.Name,
+		Description:  p.Description,
+		Category:     p.Category,
+		Style:        p.Style,
+		Image:        p.Image,
+		Price:        p.Price,
+		CurrentStock: int32(p.CurrentStock),
+		Featured:     p.Featured,
+		ImageLabels:  toPbLabels(p.ImageLabels),
+	}
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	product := RepoFindProduct(req.ProductId)
+	if !product.Initialized() {
+		return nil, status.Errorf(codes.NotFound, "product %s not found", req.ProductId)
+	}
+
+	fullyQualifyProductImageURLGRPC(grpcImageURLOption{fullyQualify: req.FullyQualifyImageUrls}, &product)
+	return toPbProduct(product), nil
+}
+
+func (s *productServer) BatchGetProducts(ctx context.Context, req *pb.BatchGetProductsRequest) (*pb.BatchGetProductsResponse, error) {
+	if len(req.ProductIds) > MAX_BATCH_GET_ITEM {
+		return nil, status.Errorf(codes.InvalidArgument, "maximum number of product IDs per request is %d", MAX_BATCH_GET_ITEM)
+	}
+
+	products := RepoFindMultipleProducts(req.ProductIds)
+
+	opt := grpcImageURLOption{fullyQualify: req.FullyQualifyImageUrls}
+	resp := &pb.BatchGetProductsResponse{}
+	for _, product := range products {
+		fullyQualifyProductImageURLGRPC(opt, &product)
+		resp.Products = append(resp.Products, toPbProduct(product))
+	}
+	return resp, nil
+}
+
+func (s *productServer) ListByCategory(ctx context.Context, req *pb.ListByCategoryRequest) (*pb.ProductList, error) {
+	products := RepoFindProductByCategory(req.CategoryName)
+
+	opt := grpcImageURLOption{fullyQualify: req.FullyQualifyImageUrls}
+	list := &pb.ProductList{}
+	for _, product := range products {
+		fullyQualifyProductImageURLGRPC(opt, &product)
+		list.Products = append(list.Products, toPbProduct(product))
+	}
+	return list, nil
+}
+
+func (s *productServer) ListFeatured(ctx context.Context, req *pb.ListFeaturedRequest) (*pb.ProductList, error) {
+	products := RepoFindFeatured()
+
+	opt := grpcImageURLOption{fullyQualify: req.FullyQualifyImageUrls}
+	list := &pb.ProductList{}
+	for _, product := range products {
+		fullyQualifyProductImageURLGRPC(opt, &product)
+		list.Products = append(list.Products, toPbProduct(product))
+	}
+	return list, nil
+}
+
+func (s *productServer) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error) {
+	product := Product{
+		Name:         req.Product.Name,
+		Description:  req.Product.Description,
+		Category:     req.Product.Category,
+		Style:        req.Product.Style,
+		Image:        req.Product.Image,
+		Price:        req.Product.Price,
+		CurrentStock: int(req.Product.CurrentStock),
+	}
+
+	if err := validateProduct(&product); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := RepoNewProduct(&product); err != nil {
+		return nil, status.Error(codes.Internal, "internal error creating product")
+	}
+
+	enqueueLabelJob(product)
+	return toPbProduct(product), nil
+}
+
+func (s *productServer) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.Product, error) {
+	existingProduct := RepoFindProduct(req.ProductId)
+	if !existingProduct.Initialized() {
+		return nil, status.Errorf(codes.NotFound, "product %s not found", req.ProductId)
+	}
+
+	product := Product{
+		Name:         req.Product.Name,
+		Description:  req.Product.Description,
+		Category:     req.Product.Category,
+		Style:        req.Product.Style,
+		Image:        req.Product.Image,
+		Price:        req.Product.Price,
+		CurrentStock: int(req.Product.CurrentStock),
+	}
+
+	if err := validateProduct(&product); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := RepoUpdateProduct(&existingProduct, &product); err != nil {
+		return nil, status.Error(codes.Internal, "internal error updating product")
+	}
+
+	enqueueLabelJob(product)
+	return toPbProduct(product), nil
+}
+
+func (s *productServer) UpdateInventory(ctx context.Context, req *pb.UpdateInventoryRequest) (*pb.Product, error) {
+	product := RepoFindProduct(req.ProductId)
+	if !product.Initialized() {
+		return nil, status.Errorf(codes.NotFound, "product %s not found", req.ProductId)
+	}
+
+	if err := RepoUpdateInventoryDelta(&product, int(req.StockDelta)); err != nil {
+		if errors.Is(err, ErrInsufficientStock) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return toPbProduct(product), nil
+}
+
+func (s *productServer) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
+	product := RepoFindProduct(req.ProductId)
+	if !product.Initialized() {
+		return nil, status.Errorf(codes.NotFound, "product %s not found", req.ProductId)
+	}
+
+	if err := RepoDeleteProduct(&product); err != nil {
+		return nil, status.Error(codes.Internal, "internal error deleting product")
+	}
+
+	return &pb.DeleteProductResponse{Deleted: true}, nil
+}
+
+// Bounds for StreamCatalogRequest.PageSize: a zero/negative value falls back to
+// defaultStreamPageSize, and anything larger is clamped to maxStreamPageSize so one
+// caller can't force an unbounded DynamoDB Scan page.
+const (
+	defaultStreamPageSize = 100
+	maxStreamPageSize     = 500
+)
+
+// StreamCatalog - pages through DynamoDB via RepoScanProductsPage and streams each page to
+// the caller as it's read, instead of buffering the whole catalog in memory first, so
+// downstream personalization/search indexers can pull the full catalog without paginating
+// over HTTP.
+func (s *productServer) StreamCatalog(req *pb.StreamCatalogRequest, stream pb.ProductService_StreamCatalogServer) error {
+	opt := grpcImageURLOption{fullyQualify: req.FullyQualifyImageUrls}
+
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize <= 0:
+		pageSize = defaultStreamPageSize
+	case pageSize > maxStreamPageSize:
+		pageSize = maxStreamPageSize
+	}
+
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
+	for {
+		products, nextKey := RepoScanProductsPage(lastEvaluatedKey, pageSize)
+
+		for _, product := range products {
+			fullyQualifyProductImageURLGRPC(opt, &product)
+			if err := stream.Send(toPbProduct(product)); err != nil {
+				return err
+			}
+		}
+
+		if len(nextKey) == 0 {
+			return nil
+		}
+		lastEvaluatedKey = nextKey
+	}
+}
+
+// StartGRPCServer - starts the gRPC transport on its own port alongside the mux HTTP
+// server, sharing the same Repo* functions and DynamoDB/Rekognition clients. Blocks
+// serving the listener, so callers should invoke it as `go StartGRPCServer(grpcPort)`
+// next to the HTTP server's ListenAndServe, the same way main wires up every other
+// background listener in this service.
+func StartGRPCServer(port string) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return fmt.Errorf("listening on port %s: %w", port, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterProductServiceServer(grpcServer, &productServer{})
+
+	return grpcServer.Serve(listener)
+}
@@ -12,12 +12,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/rekognition"
 
 	"strconv"
@@ -29,8 +28,11 @@ var missingImageFile = "product_image_coming_soon.png"
 
 // ConfidenceLabel struct
 type ConfidenceLabel struct {
-	Name       string  `dynamodbav:"name"`
-	Confidence float64 `dynamodbav:"confidence"`
+	Name        string  `dynamodbav:"name"`
+	Confidence  float64 `dynamodbav:"confidence"`
+	Source      string  `dynamodbav:"source"`
+	Uncertainty float64 `dynamodbav:"uncertainty"`
+	UpdatedAt   string  `dynamodbav:"updated_at"`
 }
 
 // initResponse
@@ -92,7 +94,7 @@ func fullyQualifyProductImageURLs(r *http.Request, products *Products) {
 }
 
 // detectLabels - Generate labels for input image via AWS Rekognition API
-func detectLabels(image string) []*dynamodb.AttributeValue {
+func detectLabels(image string) []ConfidenceLabel {
 	// Call Rekognition API
 	result, err := rekognitionClient.DetectLabels(
 		&rekognition.DetectLabelsInput{
@@ -111,46 +113,31 @@ func detectLabels(image string) []*dynamodb.AttributeValue {
 
 	// Build label structs from Rekognition result
 	var cl []ConfidenceLabel
+	now := time.Now().UTC().Format(time.RFC3339)
 	for _, label := range result.Labels {
-		attrv := ConfidenceLabel{
+		cl = append(cl, ConfidenceLabel{
 			Name:       *label.Name,
 			Confidence: *label.Confidence,
-		}
-		cl = append(cl, attrv)
-	}
-	// Convert slice of ConfidenceLabels to slice of AttributeValues
-	list, err := dynamodbattribute.MarshalList(cl)
-	if err != nil {
-		fmt.Println("Got error marshalling:")
-		fmt.Println(err.Error())
+			Source:     LabelSourceRekognition,
+			UpdatedAt:  now,
+		})
 	}
-	// Return list of labels and their confidence
-	return list
+	return cl
 }
 
-// addLabels - Add image labels to a product record in DynamoDB
+// addLabels - detects labels for a product's image and merges them into the record,
+// preserving any manually-curated labels already present. Used as the synchronous
+// fallback when no label queue is configured; goes through updateLabels' optimistic
+// lock like every other writer of image_labels, since this can otherwise race the
+// labeler worker's write-back or a concurrent manual curation request.
 func addLabels(p Product) {
 	s3Key := "images/" + p.Category + "/" + p.Image
+	detected := detectLabels(s3Key)
 
-	// Update the DynamoDB record for the product with labels from Rekognition
-	_, err = dynamoClient.UpdateItem(
-		&dynamodb.UpdateItemInput{
-			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-				":labels": {
-					L: detectLabels(s3Key),
-				},
-			},
-			TableName: aws.String(ddbTableProducts),
-			Key: map[string]*dynamodb.AttributeValue{
-				"id": {
-					S: aws.String(p.ID),
-				},
-			},
-			ReturnValues:     aws.String("UPDATED_NEW"),
-			UpdateExpression: aws.String("set image_labels = :labels"),
-		})
-	if err != nil {
-		fmt.Println("Got error calling UpdateItem:")
+	if _, err := updateLabels(p.ID, func(existing []ConfidenceLabel) []ConfidenceLabel {
+		return mergeAutoLabels(existing, detected)
+	}); err != nil {
+		fmt.Println("Got error updating labels:")
 		fmt.Println(err.Error())
 	}
 }
@@ -336,52 +323,7 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	addLabels(product)
-	fullyQualifyProductImageURL(r, &product)
-
-	if err := json.NewEncoder(w).Encode(product); err != nil {
-		panic(err)
-	}
-}
-
-// UpdateInventory - updates stock quantity for one item
-func UpdateInventory(w http.ResponseWriter, r *http.Request) {
-	initResponse(&w)
-
-	vars := mux.Vars(r)
-
-	var inventory Inventory
-
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
-	if err != nil {
-		panic(err)
-	}
-	if err := r.Body.Close(); err != nil {
-		panic(err)
-	}
-	log.Println("UpdateInventory Body ", body)
-
-	if err := json.Unmarshal(body, &inventory); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusUnprocessableEntity)
-		if err := json.NewEncoder(w).Encode(err); err != nil {
-			panic(err)
-		}
-	}
-
-	log.Println("UpdateInventory --> ", inventory)
-
-	// Get the current product
-	product := RepoFindProduct(vars["productID"])
-	if !product.Initialized() {
-		// Existing product does not exist
-		http.Error(w, "Product not found", http.StatusNotFound)
-		return
-	}
-
-	if err := RepoUpdateInventoryDelta(&product, inventory.StockDelta); err != nil {
-		panic(err)
-	}
-
+	enqueueLabelJob(product)
 	fullyQualifyProductImageURL(r, &product)
 
 	if err := json.NewEncoder(w).Encode(product); err != nil {
@@ -420,7 +362,7 @@ func NewProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	addLabels(product)
+	enqueueLabelJob(product)
 	fullyQualifyProductImageURL(r, &product)
 
 	if err := json.NewEncoder(w).Encode(product); err != nil {
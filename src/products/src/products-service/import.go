@@ -0,0 +1,205 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/zxkane/retail-demo-store/src/products/src/products-service/importer"
+)
+
+// batchWriteChunkSize - DynamoDB BatchWriteItem accepts at most 25 items per call
+const batchWriteChunkSize = 25
+
+// batchWriteMaxRetries - maximum number of retries for UnprocessedItems before giving up
+const batchWriteMaxRetries = 5
+
+// importRowError - a single row that failed validation or import
+type importRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// importResult - summary returned from a bulk catalog import
+type importResult struct {
+	Imported   int              `json:"imported"`
+	Failed     []importRowError `json:"failed"`
+	DurationMs int64            `json:"durationMs"`
+}
+
+// rowToProduct - converts a mapped importer.Row into a Product using the configured
+// field mapping; unmapped fields are left at their zero value. A price/currentStock
+// value that is present but not parseable as a number is reported as an error rather
+// than silently importing a $0/0-stock product.
+func rowToProduct(row importer.Row) (Product, error) {
+	product := Product{
+		ID:          uuid.New().String(),
+		Name:        row["name"],
+		Description: row["description"],
+		Category:    row["category"],
+		Image:       row["image"],
+	}
+
+	if value := row["price"]; len(value) > 0 {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Product{}, fmt.Errorf("invalid price %q: %w", value, err)
+		}
+		product.Price = price
+	}
+
+	if value := row["currentStock"]; len(value) > 0 {
+		stock, err := strconv.Atoi(value)
+		if err != nil {
+			return Product{}, fmt.Errorf("invalid currentStock %q: %w", value, err)
+		}
+		product.CurrentStock = stock
+	}
+
+	return product, nil
+}
+
+// batchWriteProducts - writes products to DynamoDB in chunks of batchWriteChunkSize,
+// retrying UnprocessedItems with exponential backoff
+func batchWriteProducts(products []Product) error {
+	for start := 0; start < len(products); start += batchWriteChunkSize {
+		end := start + batchWriteChunkSize
+		if end > len(products) {
+			end = len(products)
+		}
+
+		writeRequests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, product := range products[start:end] {
+			item, err := dynamodbattribute.MarshalMap(product)
+			if err != nil {
+				return fmt.Errorf("marshalling product %s: %w", product.ID, err)
+			}
+			writeRequests = append(writeRequests, &dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{Item: item},
+			})
+		}
+
+		requestItems := map[string][]*dynamodb.WriteRequest{ddbTableProducts: writeRequests}
+
+		for attempt := 0; attempt <= batchWriteMaxRetries && len(requestItems[ddbTableProducts]) > 0; attempt++ {
+			if attempt > 0 {
+				backoff := time.Duration(1<<uint(attempt-1)) * 100 * time.Millisecond
+				backoff += time.Duration(rand.Intn(100)) * time.Millisecond
+				time.Sleep(backoff)
+			}
+
+			output, err := dynamoClient.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: requestItems})
+			if err != nil {
+				return fmt.Errorf("batch writing products: %w", err)
+			}
+
+			requestItems = output.UnprocessedItems
+			if len(requestItems) == 0 {
+				break
+			}
+		}
+
+		if len(requestItems[ddbTableProducts]) > 0 {
+			return fmt.Errorf("unable to write %d products after %d retries", len(requestItems[ddbTableProducts]), batchWriteMaxRetries)
+		}
+	}
+	return nil
+}
+
+// maxImportMultipartMemory - bytes of the multipart body kept in memory before spilling
+// uploaded parts to temp files, mirroring net/http's own ParseMultipartForm default
+const maxImportMultipartMemory = 32 << 20 // 32MB
+
+// ImportProducts Handler - POST /products/import
+// Streams a supplier catalog feed (CSV or XML, selected via the `format` query param) into
+// DynamoDB. The request is multipart/form-data carrying both the catalog file (field
+// "catalog") and the field mapping (field "mapping", a JSON object) so the two can be read
+// from the same request body.
+func ImportProducts(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	start := time.Now()
+
+	format := r.URL.Query().Get("format")
+	if format != "csv" && format != "xml" {
+		http.Error(w, "Query param format must be csv or xml", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxImportMultipartMemory); err != nil {
+		http.Error(w, "Invalid multipart request: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var mapping importer.FieldMapping
+	if err := json.Unmarshal([]byte(r.FormValue("mapping")), &mapping); err != nil {
+		http.Error(w, "Invalid field mapping payload (form field \"mapping\")", http.StatusUnprocessableEntity)
+		return
+	}
+
+	file, _, err := r.FormFile("catalog")
+	if err != nil {
+		http.Error(w, "Catalog file is required (multipart field \"catalog\")", http.StatusUnprocessableEntity)
+		return
+	}
+	defer file.Close()
+
+	var rows []importer.Row
+	if format == "csv" {
+		rows, err = importer.ParseCSV(file, mapping)
+	} else {
+		rows, err = importer.ParseXML(file, mapping)
+	}
+	if err != nil {
+		http.Error(w, "Unable to parse catalog feed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var failed []importRowError
+	var products []Product
+	for i, row := range rows {
+		product, err := rowToProduct(row)
+		if err != nil {
+			failed = append(failed, importRowError{Row: i, Error: err.Error()})
+			continue
+		}
+		if err := validateProduct(&product); err != nil {
+			failed = append(failed, importRowError{Row: i, Error: err.Error()})
+			continue
+		}
+		products = append(products, product)
+	}
+
+	if err := batchWriteProducts(products); err != nil {
+		http.Error(w, "Internal error importing catalog: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, product := range products {
+		if len(product.Image) > 0 {
+			enqueueLabelJob(product)
+		}
+	}
+
+	result := importResult{
+		Imported:   len(products),
+		Failed:     failed,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+// Package importer streams supplier catalog feeds (CSV or XML) into a slice of raw
+// field maps, ready for validation and persistence by the products service.
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// FieldMapping maps a product field name (e.g. "name", "price") to the column/element
+// name used by the supplier feed
+type FieldMapping map[string]string
+
+// Row is a single imported catalog record, keyed by product field name after mapping
+type Row map[string]string
+
+// xmlRecord and xmlField model a generic <catalog><record><title>value</title>...
+// catalog layout, matching the shape of common affiliate XML feeds: each child element of
+// a <record> is one field, keyed by its element name; the root element's name is not checked
+type xmlRecord struct {
+	Fields []xmlField `xml:",any"`
+}
+
+type xmlField struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type xmlCatalog struct {
+	Records []xmlRecord `xml:"record"`
+}
+
+// ParseCSV reads a CSV catalog feed and maps each row onto product fields using mapping.
+// The first line is treated as a header.
+func ParseCSV(r io.Reader, mapping FieldMapping) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	columnIndex := make(map[string]int, len(header))
+	for i, name := range header {
+		columnIndex[name] = i
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV row: %w", err)
+		}
+
+		row := make(Row, len(mapping))
+		for field, column := range mapping {
+			if idx, ok := columnIndex[column]; ok && idx < len(record) {
+				row[field] = record[idx]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseXML reads an XML catalog feed of <root><record><column>value</column>...</record>...
+// (any root element name) and maps each record's child elements, by element name, onto
+// product fields using mapping.
+func ParseXML(r io.Reader, mapping FieldMapping) ([]Row, error) {
+	var catalog xmlCatalog
+	if err := xml.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("decoding XML catalog: %w", err)
+	}
+
+	var rows []Row
+	for _, record := range catalog.Records {
+		fieldValues := make(map[string]string, len(record.Fields))
+		for _, f := range record.Fields {
+			fieldValues[f.XMLName.Local] = f.Value
+		}
+
+		row := make(Row, len(mapping))
+		for field, column := range mapping {
+			if value, ok := fieldValues[column]; ok {
+				row[field] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,103 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package importer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCSVMapsMappedColumnsByHeader(t *testing.T) {
+	csv := "sku,title,cost,qty\n" +
+		"A1,Widget,9.99,42\n" +
+		"A2,Gadget,19.99,7\n"
+
+	mapping := FieldMapping{
+		"name":         "title",
+		"price":        "cost",
+		"currentStock": "qty",
+	}
+
+	rows, err := ParseCSV(strings.NewReader(csv), mapping)
+	if err != nil {
+		t.Fatalf("ParseCSV returned error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	if rows[0]["name"] != "Widget" || rows[0]["price"] != "9.99" || rows[0]["currentStock"] != "42" {
+		t.Errorf("row 0 mapped incorrectly: %+v", rows[0])
+	}
+	if rows[1]["name"] != "Gadget" {
+		t.Errorf("row 1 mapped incorrectly: %+v", rows[1])
+	}
+
+	// sku has no mapping entry, so it must not show up in the row
+	if _, ok := rows[0]["sku"]; ok {
+		t.Errorf("unmapped column leaked into row: %+v", rows[0])
+	}
+}
+
+func TestParseCSVIgnoresUnmappedColumnReferences(t *testing.T) {
+	csv := "title\nWidget\n"
+
+	mapping := FieldMapping{"name": "title", "price": "cost"}
+
+	rows, err := ParseCSV(strings.NewReader(csv), mapping)
+	if err != nil {
+		t.Fatalf("ParseCSV returned error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if rows[0]["name"] != "Widget" {
+		t.Errorf("expected name to be mapped, got %+v", rows[0])
+	}
+	if _, ok := rows[0]["price"]; ok {
+		t.Errorf("price should be absent when its source column doesn't exist, got %+v", rows[0])
+	}
+}
+
+func TestParseCSVRejectsUnreadableHeader(t *testing.T) {
+	if _, err := ParseCSV(strings.NewReader(""), FieldMapping{}); err == nil {
+		t.Fatal("expected an error reading the header of an empty feed")
+	}
+}
+
+func TestParseXMLMapsFieldsByElementName(t *testing.T) {
+	xml := `<?xml version="1.0"?>
+<catalog>
+	<record>
+		<title>Widget</title>
+		<cost>9.99</cost>
+	</record>
+	<record>
+		<title>Gadget</title>
+		<cost>19.99</cost>
+	</record>
+</catalog>`
+
+	mapping := FieldMapping{"name": "title", "price": "cost"}
+
+	rows, err := ParseXML(strings.NewReader(xml), mapping)
+	if err != nil {
+		t.Fatalf("ParseXML returned error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0]["name"] != "Widget" || rows[0]["price"] != "9.99" {
+		t.Errorf("row 0 mapped incorrectly: %+v", rows[0])
+	}
+	if rows[1]["name"] != "Gadget" || rows[1]["price"] != "19.99" {
+		t.Errorf("row 1 mapped incorrectly: %+v", rows[1])
+	}
+}
+
+func TestParseXMLRejectsMalformedFeed(t *testing.T) {
+	if _, err := ParseXML(strings.NewReader("not xml"), FieldMapping{}); err == nil {
+		t.Fatal("expected an error decoding a malformed XML feed")
+	}
+}
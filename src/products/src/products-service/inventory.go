@@ -0,0 +1,281 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Inventory adjustment modes accepted on the `mode` field of an inventory update request
+const (
+	InventoryModeDelta   = "delta"
+	InventoryModeSet     = "set"
+	InventoryModeReserve = "reserve"
+	InventoryModeRelease = "release"
+)
+
+// inventoryRequest - payload for POST .../inventory, extending the original stock-delta
+// shape with a `mode` selector; the bulk `count` is read from the query string by
+// inventoryCount, not from this payload
+type inventoryRequest struct {
+	Mode       string `json:"mode"`
+	StockDelta int    `json:"stockDelta"`
+}
+
+// ErrInsufficientStock - returned when a delta/reserve would take the unreserved stock
+// (current_stock - reserved_stock) below zero, or a release would take reserved_stock
+// below zero
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrInvalidInventoryRequest - returned when the request payload itself is invalid,
+// distinct from ErrInsufficientStock so callers can map it to a 4xx response
+var ErrInvalidInventoryRequest = errors.New("invalid inventory request")
+
+// inventoryCount - reads the `count` query param used for bulk reserve/release operations,
+// defaulting to 1
+func inventoryCount(r *http.Request) int {
+	param := r.URL.Query().Get("count")
+	if len(param) == 0 {
+		return 1
+	}
+
+	count, err := strconv.Atoi(param)
+	if err != nil || count <= 0 {
+		return 1
+	}
+	return count
+}
+
+// RepoUpdateInventory - dispatches a stock adjustment to the mode-appropriate Repo
+// function, each applying its change atomically with a single DynamoDB UpdateItem so
+// concurrent UpdateInventory calls can't race each other into an oversold state.
+func RepoUpdateInventory(p *Product, mode string, delta int, count int) error {
+	switch mode {
+	case "", InventoryModeDelta:
+		return RepoUpdateInventoryDelta(p, delta)
+	case InventoryModeSet:
+		return setStock(p, delta)
+	case InventoryModeReserve:
+		return reserveStock(p, count)
+	case InventoryModeRelease:
+		return releaseStock(p, count)
+	default:
+		return fmt.Errorf("%w: invalid inventory mode: %s", ErrInvalidInventoryRequest, mode)
+	}
+}
+
+// RepoUpdateInventoryDelta - ADD current_stock :delta in a single conditional UpdateItem,
+// conditioned on the unreserved stock (current_stock - reserved_stock) covering the
+// decrement when delta is negative, so a sale can never eat into units reserveStock
+// already promised to a pending order. Replaces the former read-modify-write, which raced
+// concurrent callers.
+func RepoUpdateInventoryDelta(p *Product, delta int) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(ddbTableProducts),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(p.ID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":delta": {N: aws.String(strconv.Itoa(delta))},
+		},
+		UpdateExpression: aws.String("ADD current_stock :delta"),
+		ReturnValues:     aws.String("ALL_NEW"),
+	}
+
+	if delta < 0 {
+		input.ExpressionAttributeValues[":neededAbs"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(-delta))}
+		input.ExpressionAttributeValues[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+		input.ConditionExpression = aws.String("current_stock - if_not_exists(reserved_stock, :zero) >= :neededAbs")
+		input.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+
+	output, err := dynamoClient.UpdateItem(input)
+	if err != nil {
+		if applyConditionalCheckFailureAttributes(p, err) {
+			return ErrInsufficientStock
+		}
+		return err
+	}
+
+	return applyUpdatedAttributes(p, output.Attributes)
+}
+
+// setStock - overwrites current_stock with an absolute value
+func setStock(p *Product, value int) error {
+	if value < 0 {
+		return fmt.Errorf("%w: current stock cannot be a negative value", ErrInvalidInventoryRequest)
+	}
+
+	output, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(ddbTableProducts),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(p.ID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":value": {N: aws.String(strconv.Itoa(value))},
+		},
+		UpdateExpression: aws.String("SET current_stock = :value"),
+		ReturnValues:     aws.String("ALL_NEW"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return applyUpdatedAttributes(p, output.Attributes)
+}
+
+// reserveStock - holds `count` units of inventory for a pending order without touching
+// sellable current_stock, conditioned on enough unreserved stock being available
+func reserveStock(p *Product, count int) error {
+	output, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(ddbTableProducts),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(p.ID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":count":  {N: aws.String(strconv.Itoa(count))},
+			":zero":   {N: aws.String("0")},
+			":needed": {N: aws.String(strconv.Itoa(count))},
+		},
+		UpdateExpression:                    aws.String("ADD reserved_stock :count"),
+		ConditionExpression:                 aws.String("current_stock - if_not_exists(reserved_stock, :zero) >= :needed"),
+		ReturnValues:                        aws.String("ALL_NEW"),
+		ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+	})
+	if err != nil {
+		if applyConditionalCheckFailureAttributes(p, err) {
+			return ErrInsufficientStock
+		}
+		return err
+	}
+
+	return applyUpdatedAttributes(p, output.Attributes)
+}
+
+// releaseStock - releases `count` previously-reserved units, conditioned on never taking
+// reserved_stock below zero
+func releaseStock(p *Product, count int) error {
+	output, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(ddbTableProducts),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(p.ID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":count":  {N: aws.String(strconv.Itoa(-count))},
+			":needed": {N: aws.String(strconv.Itoa(count))},
+		},
+		UpdateExpression:    aws.String("ADD reserved_stock :count"),
+		ConditionExpression: aws.String("reserved_stock >= :needed"),
+		ReturnValues:        aws.String("ALL_NEW"),
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return ErrInsufficientStock
+		}
+		return err
+	}
+
+	return applyUpdatedAttributes(p, output.Attributes)
+}
+
+// applyUpdatedAttributes - copies the fields DynamoDB returned from the conditional
+// UpdateItem back onto the in-memory product so the HTTP response reflects reality
+func applyUpdatedAttributes(p *Product, attributes map[string]*dynamodb.AttributeValue) error {
+	if stock, ok := attributes["current_stock"]; ok && stock.N != nil {
+		value, err := strconv.Atoi(*stock.N)
+		if err != nil {
+			return err
+		}
+		p.CurrentStock = value
+	}
+	return nil
+}
+
+func isConditionalCheckFailure(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
+	return false
+}
+
+// applyConditionalCheckFailureAttributes - on a failed conditional UpdateItem, copies the
+// item's current attributes back onto p so a 409 response reports the real current stock
+// rather than the pre-update value the handler read at the top of the request. Relies on
+// ReturnValuesOnConditionCheckFailure: ALL_OLD being set on the request, which populates
+// ConditionalCheckFailedException.Item. Returns whether err was in fact a conditional
+// check failure.
+func applyConditionalCheckFailureAttributes(p *Product, err error) bool {
+	var ccf *dynamodb.ConditionalCheckFailedException
+	if !errors.As(err, &ccf) {
+		return false
+	}
+	if ccf.Item != nil {
+		_ = applyUpdatedAttributes(p, ccf.Item)
+	}
+	return true
+}
+
+// UpdateInventory - updates stock quantity for one item; mode selects delta/set/reserve/
+// release semantics, and `count` bulk-sizes reserve/release operations
+func UpdateInventory(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	vars := mux.Vars(r)
+
+	var req inventoryRequest
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if err := r.Body.Close(); err != nil {
+		panic(err)
+	}
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusUnprocessableEntity)
+		return
+	}
+
+	product := RepoFindProduct(vars["productID"])
+	if !product.Initialized() {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	if err := RepoUpdateInventory(&product, req.Mode, req.StockDelta, inventoryCount(r)); err != nil {
+		if errors.Is(err, ErrInsufficientStock) {
+			w.WriteHeader(http.StatusConflict)
+			if err := json.NewEncoder(w).Encode(product); err != nil {
+				panic(err)
+			}
+			return
+		}
+		if errors.Is(err, ErrInvalidInventoryRequest) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fullyQualifyProductImageURL(r, &product)
+
+	if err := json.NewEncoder(w).Encode(product); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockInventoryClient records the UpdateItemInput of the last UpdateItem call and
+// returns a canned response, so tests can assert RepoUpdateInventory dispatches to the
+// right conditional-write shape for each mode without hitting real DynamoDB
+type mockInventoryClient struct {
+	dynamodbiface.DynamoDBAPI
+
+	lastInput *dynamodb.UpdateItemInput
+	output    *dynamodb.UpdateItemOutput
+}
+
+func (m *mockInventoryClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.lastInput = input
+	return m.output, nil
+}
+
+func withMockInventoryClient(t *testing.T, mock *mockInventoryClient) {
+	t.Helper()
+	original := dynamoClient
+	dynamoClient = mock
+	t.Cleanup(func() { dynamoClient = original })
+}
+
+func newStockOutput(stock int) *dynamodb.UpdateItemOutput {
+	return &dynamodb.UpdateItemOutput{
+		Attributes: map[string]*dynamodb.AttributeValue{
+			"current_stock": {N: aws.String(strconv.Itoa(stock))},
+		},
+	}
+}
+
+func TestRepoUpdateInventoryDispatchesDeltaByDefault(t *testing.T) {
+	mock := &mockInventoryClient{output: newStockOutput(5)}
+	withMockInventoryClient(t, mock)
+
+	p := &Product{ID: "p1"}
+	if err := RepoUpdateInventory(p, "", -3, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if aws.StringValue(mock.lastInput.UpdateExpression) != "ADD current_stock :delta" {
+		t.Errorf("expected a delta ADD, got %q", aws.StringValue(mock.lastInput.UpdateExpression))
+	}
+	if p.CurrentStock != 5 {
+		t.Errorf("expected product to reflect the updated stock, got %d", p.CurrentStock)
+	}
+}
+
+func TestRepoUpdateInventoryDispatchesSet(t *testing.T) {
+	mock := &mockInventoryClient{output: newStockOutput(10)}
+	withMockInventoryClient(t, mock)
+
+	p := &Product{ID: "p1"}
+	if err := RepoUpdateInventory(p, InventoryModeSet, 10, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if aws.StringValue(mock.lastInput.UpdateExpression) != "SET current_stock = :value" {
+		t.Errorf("expected a SET, got %q", aws.StringValue(mock.lastInput.UpdateExpression))
+	}
+}
+
+func TestRepoUpdateInventoryRejectsNegativeSet(t *testing.T) {
+	mock := &mockInventoryClient{output: newStockOutput(0)}
+	withMockInventoryClient(t, mock)
+
+	p := &Product{ID: "p1"}
+	err := RepoUpdateInventory(p, InventoryModeSet, -1, 0)
+	if !errors.Is(err, ErrInvalidInventoryRequest) {
+		t.Fatalf("expected ErrInvalidInventoryRequest, got %v", err)
+	}
+	if mock.lastInput != nil {
+		t.Error("expected setStock to reject before calling UpdateItem")
+	}
+}
+
+func TestRepoUpdateInventoryDispatchesReserve(t *testing.T) {
+	mock := &mockInventoryClient{output: newStockOutput(5)}
+	withMockInventoryClient(t, mock)
+
+	p := &Product{ID: "p1"}
+	if err := RepoUpdateInventory(p, InventoryModeReserve, 0, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if aws.StringValue(mock.lastInput.UpdateExpression) != "ADD reserved_stock :count" {
+		t.Errorf("expected a reserve ADD, got %q", aws.StringValue(mock.lastInput.UpdateExpression))
+	}
+}
+
+func TestRepoUpdateInventoryDispatchesRelease(t *testing.T) {
+	mock := &mockInventoryClient{output: newStockOutput(5)}
+	withMockInventoryClient(t, mock)
+
+	p := &Product{ID: "p1"}
+	if err := RepoUpdateInventory(p, InventoryModeRelease, 0, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if aws.StringValue(mock.lastInput.UpdateExpression) != "ADD reserved_stock :count" {
+		t.Errorf("expected a release ADD, got %q", aws.StringValue(mock.lastInput.UpdateExpression))
+	}
+	if aws.StringValue(mock.lastInput.ConditionExpression) != "reserved_stock >= :needed" {
+		t.Errorf("expected release's own condition, got %q", aws.StringValue(mock.lastInput.ConditionExpression))
+	}
+}
+
+func TestRepoUpdateInventoryRejectsUnknownMode(t *testing.T) {
+	mock := &mockInventoryClient{output: newStockOutput(0)}
+	withMockInventoryClient(t, mock)
+
+	p := &Product{ID: "p1"}
+	err := RepoUpdateInventory(p, "bogus", 0, 0)
+	if !errors.Is(err, ErrInvalidInventoryRequest) {
+		t.Fatalf("expected ErrInvalidInventoryRequest, got %v", err)
+	}
+}
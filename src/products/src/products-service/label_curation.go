@@ -0,0 +1,253 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/zxkane/retail-demo-store/src/products/src/products-service/labeler"
+)
+
+// maxLabelWriteRetries - retries for the optimistic-lock write in updateLabels before
+// giving up and reporting a conflict; covers the common case of racing a single other
+// writer (manual curation vs. the labeler worker's write-back)
+const maxLabelWriteRetries = 3
+
+// Label sources recorded on a ConfidenceLabel's Source field
+const (
+	LabelSourceRekognition = "rekognition"
+	LabelSourceManual      = "manual"
+	LabelSourceImported    = "imported"
+)
+
+// manualLabelRequest - payload for curating a single image label
+type manualLabelRequest struct {
+	Name        string  `json:"name"`
+	Confidence  float64 `json:"confidence"`
+	Uncertainty float64 `json:"uncertainty"`
+}
+
+// mergeManualLabel - upserts a manually-curated label into a product's label list by name
+// (case-insensitive), replacing any existing entry with the same name
+func mergeManualLabel(existing []ConfidenceLabel, label ConfidenceLabel) []ConfidenceLabel {
+	lowerName := strings.ToLower(label.Name)
+	for i, l := range existing {
+		if strings.ToLower(l.Name) == lowerName {
+			existing[i] = label
+			return existing
+		}
+	}
+	return append(existing, label)
+}
+
+// removeLabel - removes a label by name (case-insensitive) from a product's label list
+func removeLabel(existing []ConfidenceLabel, name string) []ConfidenceLabel {
+	lowerName := strings.ToLower(name)
+	filtered := existing[:0]
+	for _, l := range existing {
+		if strings.ToLower(l.Name) != lowerName {
+			filtered = append(filtered, l)
+		}
+	}
+	return filtered
+}
+
+// toLabelerLabels / fromLabelerLabels - convert between the products service's
+// ConfidenceLabel and the labeler package's shared Label, which have identical shapes
+func toLabelerLabels(labels []ConfidenceLabel) []labeler.Label {
+	converted := make([]labeler.Label, len(labels))
+	for i, l := range labels {
+		converted[i] = labeler.Label(l)
+	}
+	return converted
+}
+
+func fromLabelerLabels(labels []labeler.Label) []ConfidenceLabel {
+	converted := make([]ConfidenceLabel, len(labels))
+	for i, l := range labels {
+		converted[i] = ConfidenceLabel(l)
+	}
+	return converted
+}
+
+// mergeAutoLabels - merges freshly-detected Rekognition labels into a product's existing
+// label list, keeping any manually-curated entries untouched and refreshing auto ones.
+// Delegates to labeler.MergeAutoLabels, which cmd/labeler-worker shares, so the two
+// services can't drift on merge semantics.
+func mergeAutoLabels(existing []ConfidenceLabel, detected []ConfidenceLabel) []ConfidenceLabel {
+	merged := labeler.MergeAutoLabels(toLabelerLabels(existing), toLabelerLabels(detected))
+	return fromLabelerLabels(merged)
+}
+
+// updateLabels - reads a product's current image_labels under labeler's optimistic
+// lock, applies mutate, and writes the result back conditioned on no one else (manual
+// curation, the labeler worker's write-back, another curation request) having written
+// image_labels in the meantime. Retries on a lost race, since the common case is
+// colliding with exactly one other writer.
+func updateLabels(productID string, mutate func([]ConfidenceLabel) []ConfidenceLabel) ([]ConfidenceLabel, error) {
+	var labels []ConfidenceLabel
+
+	for attempt := 0; attempt <= maxLabelWriteRetries; attempt++ {
+		existing, version, err := labeler.ReadLabels(dynamoClient, ddbTableProducts, productID)
+		if err != nil {
+			return nil, err
+		}
+
+		labels = mutate(fromLabelerLabels(existing))
+
+		err = labeler.WriteLabels(dynamoClient, ddbTableProducts, productID, toLabelerLabels(labels), version)
+		if err == nil {
+			return labels, nil
+		}
+		if !errors.Is(err, labeler.ErrVersionConflict) {
+			return nil, err
+		}
+	}
+
+	return nil, labeler.ErrVersionConflict
+}
+
+// AddProductLabel Handler - POST /products/{id}/labels
+// Adds (or replaces, by name) a manually-curated image label on a product
+func AddProductLabel(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	vars := mux.Vars(r)
+
+	product := RepoFindProduct(vars["productID"])
+	if !product.Initialized() {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if err := r.Body.Close(); err != nil {
+		panic(err)
+	}
+
+	var req manualLabelRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(req.Name) == 0 {
+		http.Error(w, "Label name is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	label := ConfidenceLabel{
+		Name:        req.Name,
+		Confidence:  req.Confidence,
+		Source:      LabelSourceManual,
+		Uncertainty: req.Uncertainty,
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	labels, err := updateLabels(product.ID, func(existing []ConfidenceLabel) []ConfidenceLabel {
+		return mergeManualLabel(existing, label)
+	})
+	if err != nil {
+		writeLabelUpdateError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(labels); err != nil {
+		panic(err)
+	}
+}
+
+// UpdateProductLabel Handler - PUT /products/{id}/labels/{name}
+// Sets the confidence/uncertainty of the named label (matched case-insensitively) and marks
+// it manually-curated; if the product has no label of that name, one is added
+func UpdateProductLabel(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	vars := mux.Vars(r)
+
+	product := RepoFindProduct(vars["productID"])
+	if !product.Initialized() {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
+	if err != nil {
+		panic(err)
+	}
+	if err := r.Body.Close(); err != nil {
+		panic(err)
+	}
+
+	var req manualLabelRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusUnprocessableEntity)
+		return
+	}
+
+	label := ConfidenceLabel{
+		Name:        vars["name"],
+		Confidence:  req.Confidence,
+		Source:      LabelSourceManual,
+		Uncertainty: req.Uncertainty,
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	labels, err := updateLabels(product.ID, func(existing []ConfidenceLabel) []ConfidenceLabel {
+		return mergeManualLabel(existing, label)
+	})
+	if err != nil {
+		writeLabelUpdateError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(labels); err != nil {
+		panic(err)
+	}
+}
+
+// DeleteProductLabel Handler - DELETE /products/{id}/labels/{name}
+func DeleteProductLabel(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	vars := mux.Vars(r)
+
+	product := RepoFindProduct(vars["productID"])
+	if !product.Initialized() {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	labels, err := updateLabels(product.ID, func(existing []ConfidenceLabel) []ConfidenceLabel {
+		return removeLabel(existing, vars["name"])
+	})
+	if err != nil {
+		writeLabelUpdateError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(labels); err != nil {
+		panic(err)
+	}
+}
+
+// writeLabelUpdateError - maps updateLabels' errors to an HTTP response: a version
+// conflict the caller should retry is a 409, anything else is a 500
+func writeLabelUpdateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, labeler.ErrVersionConflict) {
+		http.Error(w, "Labels were modified concurrently; please retry", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Internal error updating labels", http.StatusInternalServerError)
+}
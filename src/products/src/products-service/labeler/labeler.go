@@ -0,0 +1,191 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+// Package labeler enqueues and decodes asynchronous Rekognition label jobs exchanged
+// between the products service and cmd/labeler-worker over SQS.
+package labeler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// Label job status values, persisted on a product's labels_status attribute
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// SourceManual - the Label.Source value for merchandiser-curated labels, which
+// MergeAutoLabels never overwrites with fresh Rekognition output
+const SourceManual = "manual"
+
+// Label mirrors the products service's ConfidenceLabel record shape, shared so both the
+// products service and cmd/labeler-worker merge labels with identical semantics
+type Label struct {
+	Name        string  `dynamodbav:"name"`
+	Confidence  float64 `dynamodbav:"confidence"`
+	Source      string  `dynamodbav:"source"`
+	Uncertainty float64 `dynamodbav:"uncertainty"`
+	UpdatedAt   string  `dynamodbav:"updated_at"`
+}
+
+// MergeAutoLabels - merges freshly-detected Rekognition labels into a product's existing
+// label list, keeping any manually-curated entries untouched and refreshing auto ones
+func MergeAutoLabels(existing []Label, detected []Label) []Label {
+	manual := make(map[string]Label)
+	for _, l := range existing {
+		if l.Source == SourceManual {
+			manual[strings.ToLower(l.Name)] = l
+		}
+	}
+
+	merged := make([]Label, 0, len(detected)+len(manual))
+	for _, l := range detected {
+		if _, isManual := manual[strings.ToLower(l.Name)]; isManual {
+			continue
+		}
+		merged = append(merged, l)
+	}
+	for _, l := range manual {
+		merged = append(merged, l)
+	}
+	return merged
+}
+
+// Job - a label-detection job enqueued for a single product image
+type Job struct {
+	ProductID string `json:"productId"`
+	S3Key     string `json:"s3Key"`
+	ImageETag string `json:"imageETag"`
+}
+
+// DedupeKey - a stable per-image dedup key combining the product ID and image ETag, used
+// as the SQS FIFO MessageDeduplicationId so re-enqueuing the same image is a no-op
+func DedupeKey(productID string, imageETag string) string {
+	sum := sha256.Sum256([]byte(productID + imageETag))
+	return hex.EncodeToString(sum[:])
+}
+
+// Enqueue - sends a label job to the queue, deduplicated on productID+imageETag
+func Enqueue(client sqsiface.SQSAPI, queueURL string, job Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshalling label job: %w", err)
+	}
+
+	dedupeKey := DedupeKey(job.ProductID, job.ImageETag)
+
+	_, err = client.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:               aws.String(queueURL),
+		MessageBody:            aws.String(string(body)),
+		MessageGroupId:         aws.String(job.ProductID),
+		MessageDeduplicationId: aws.String(dedupeKey),
+	})
+	if err != nil {
+		return fmt.Errorf("enqueuing label job for product %s: %w", job.ProductID, err)
+	}
+	return nil
+}
+
+// Decode - parses a label job out of an SQS message body
+func Decode(body string) (Job, error) {
+	var job Job
+	if err := json.Unmarshal([]byte(body), &job); err != nil {
+		return Job{}, fmt.Errorf("decoding label job: %w", err)
+	}
+	return job, nil
+}
+
+// ErrVersionConflict - returned by WriteLabels when the product's image_labels were
+// written by someone else (manual curation, the worker, or another curation request)
+// since ReadLabels last observed them
+var ErrVersionConflict = errors.New("image_labels were modified concurrently")
+
+// ReadLabels - reads a product's current image_labels along with the optimistic-lock
+// version (labels_version) those labels were last written at
+func ReadLabels(client dynamodbiface.DynamoDBAPI, table string, productID string) ([]Label, int64, error) {
+	output, err := client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(productID)},
+		},
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if output.Item == nil {
+		return nil, 0, nil
+	}
+
+	var labels []Label
+	if attr, ok := output.Item["image_labels"]; ok {
+		if err := dynamodbattribute.UnmarshalList(attr.L, &labels); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var version int64
+	if attr, ok := output.Item["labels_version"]; ok && attr.N != nil {
+		version, err = strconv.ParseInt(*attr.N, 10, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return labels, version, nil
+}
+
+// WriteLabels - overwrites image_labels, conditioned on labels_version still matching
+// expectedVersion (as returned by ReadLabels), and advances labels_version by one. Returns
+// ErrVersionConflict if another writer updated the labels in the meantime.
+func WriteLabels(client dynamodbiface.DynamoDBAPI, table string, productID string, labels []Label, expectedVersion int64) error {
+	list, err := dynamodbattribute.MarshalList(labels)
+	if err != nil {
+		return fmt.Errorf("marshalling labels: %w", err)
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(productID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":labels":  {L: list},
+			":version": {N: aws.String(strconv.FormatInt(expectedVersion+1, 10))},
+		},
+		UpdateExpression: aws.String("set image_labels = :labels, labels_version = :version"),
+	}
+
+	if expectedVersion == 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(labels_version) OR labels_version = :expected")
+		input.ExpressionAttributeValues[":expected"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	} else {
+		input.ConditionExpression = aws.String("labels_version = :expected")
+		input.ExpressionAttributeValues[":expected"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expectedVersion, 10))}
+	}
+
+	_, err = client.UpdateItem(input)
+	if err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrVersionConflict
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/zxkane/retail-demo-store/src/products/src/products-service/labeler"
+)
+
+var labelsQueueURL = os.Getenv("LABELS_QUEUE_URL")
+
+// enqueueLabelJob - enqueues a label-detection job for a product's image instead of
+// calling Rekognition inline, keeping DetectLabels latency and throttling off the
+// request path. Falls back to the old synchronous path if no queue is configured.
+func enqueueLabelJob(p Product) {
+	if len(p.Image) == 0 {
+		return
+	}
+
+	if len(labelsQueueURL) == 0 {
+		addLabels(p)
+		return
+	}
+
+	// Mark the job pending before it is enqueued: the worker long-polls continuously and
+	// can receive, process, and write a terminal done/failed status before a status write
+	// issued after Enqueue would run, which would then clobber that terminal status back
+	// to pending.
+	if err := setLabelsStatus(p.ID, labeler.StatusPending); err != nil {
+		fmt.Println("Got error setting labels_status:")
+		fmt.Println(err.Error())
+	}
+
+	s3Key := "images/" + p.Category + "/" + p.Image
+	imageETag, err := imageObjectETag(s3Key)
+	if err != nil {
+		fmt.Println("Got error fetching image ETag:")
+		fmt.Println(err.Error())
+	}
+
+	job := labeler.Job{
+		ProductID: p.ID,
+		S3Key:     s3Key,
+		ImageETag: imageETag,
+	}
+
+	if err := labeler.Enqueue(sqsClient, labelsQueueURL, job); err != nil {
+		fmt.Println("Got error enqueuing label job:")
+		fmt.Println(err.Error())
+	}
+}
+
+// imageObjectETag - fetches the S3 object ETag for a product image, so the label job's
+// dedup key (productID+imageETag) is tied to the image's actual content instead of its
+// filename. Without this, re-uploading different content under the same image filename
+// would produce an identical dedup key and could collapse with an in-flight job still
+// processing the stale image.
+func imageObjectETag(s3Key string) (string, error) {
+	output, err := s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(os.Getenv("IMAGE_S3_BUCKET")),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("fetching ETag for %s: %w", s3Key, err)
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+// setLabelsStatus - records the current state of a product's async label job
+func setLabelsStatus(productID string, status string) error {
+	_, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(ddbTableProducts),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(productID)},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(status)},
+		},
+		UpdateExpression: aws.String("set labels_status = :status"),
+	})
+	return err
+}
+
+// ProductLabelsStatus Handler - GET /products/{id}/labels/status
+// Returns the current state (pending|done|failed) of a product's async label job
+func ProductLabelsStatus(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	vars := mux.Vars(r)
+
+	output, err := dynamoClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(ddbTableProducts),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(vars["productID"])},
+		},
+		ProjectionExpression: aws.String("labels_status"),
+	})
+	if err != nil || output.Item == nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	status := labeler.StatusDone
+	if attr, ok := output.Item["labels_status"]; ok && attr.S != nil {
+		status = *attr.S
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": status}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: MIT-0
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rekognition"
+)
+
+// defaultSimilarityThreshold - minimum score (0-1) a candidate must reach to be returned
+const defaultSimilarityThreshold = 0.1
+
+// defaultSimilarityTopN - number of ranked matches returned when the caller doesn't specify one
+const defaultSimilarityTopN = 20
+
+// maxVisualSearchImageBytes - Rekognition's own limit for images passed inline as bytes
+const maxVisualSearchImageBytes = 5 << 20 // 5MB
+
+// visualSearchRequest - JSON payload accepted by ProductSearchVisual when the caller
+// isn't posting a multipart file: either an S3 key for an image already uploaded to the
+// images bucket, or a base64-encoded image body
+type visualSearchRequest struct {
+	S3Key     string `json:"s3Key"`
+	ImageData string `json:"imageData"`
+}
+
+// scoredProduct - a product paired with its similarity score for a visual search result
+type scoredProduct struct {
+	Product
+	SimilarityScore float64 `json:"similarityScore"`
+}
+
+// similarityThreshold - reads the `threshold` query param, falling back to the default
+func similarityThreshold(r *http.Request) float64 {
+	param := r.URL.Query().Get("threshold")
+	if len(param) == 0 {
+		return defaultSimilarityThreshold
+	}
+
+	threshold, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return defaultSimilarityThreshold
+	}
+	return threshold
+}
+
+// similarityTopN - reads the `topN` query param, falling back to the default
+func similarityTopN(r *http.Request) int {
+	param := r.URL.Query().Get("topN")
+	if len(param) == 0 {
+		return defaultSimilarityTopN
+	}
+
+	topN, err := strconv.Atoi(param)
+	if err != nil || topN <= 0 {
+		return defaultSimilarityTopN
+	}
+	return topN
+}
+
+// scoreLabelOverlap - Jaccard-weighted overlap between a query label vector and a
+// candidate's labels: the sum of min(queryConfidence, candidateConfidence) over shared
+// label names, divided by the sum of query confidences
+func scoreLabelOverlap(query []ConfidenceLabel, candidate []ConfidenceLabel) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+
+	candidateConfidence := make(map[string]float64, len(candidate))
+	for _, label := range candidate {
+		candidateConfidence[strings.ToLower(label.Name)] = label.Confidence
+	}
+
+	var overlap, total float64
+	for _, label := range query {
+		total += label.Confidence
+		if cc, ok := candidateConfidence[strings.ToLower(label.Name)]; ok {
+			overlap += min(label.Confidence, cc)
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return overlap / total
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// rankByLabelSimilarity - scores every candidate against the query labels, keeping those at
+// or above threshold, and returns the top N ranked highest score first
+func rankByLabelSimilarity(query []ConfidenceLabel, candidates Products, threshold float64, topN int) []scoredProduct {
+	var ranked []scoredProduct
+	for _, candidate := range candidates {
+		score := scoreLabelOverlap(query, candidate.ImageLabels)
+		if score >= threshold {
+			ranked = append(ranked, scoredProduct{Product: candidate, SimilarityScore: score})
+		}
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].SimilarityScore > ranked[j].SimilarityScore
+	})
+
+	if len(ranked) > topN {
+		ranked = ranked[:topN]
+	}
+	return ranked
+}
+
+// detectLabelsForRekognitionImage - calls Rekognition DetectLabels against an arbitrary
+// image source and returns the resulting labels as ConfidenceLabel structs
+func detectLabelsForRekognitionImage(image *rekognition.Image) ([]ConfidenceLabel, error) {
+	result, err := rekognitionClient.DetectLabels(
+		&rekognition.DetectLabelsInput{
+			Image:     image,
+			MaxLabels: aws.Int64(10),
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var labels []ConfidenceLabel
+	for _, label := range result.Labels {
+		labels = append(labels, ConfidenceLabel{
+			Name:       *label.Name,
+			Confidence: *label.Confidence,
+		})
+	}
+	return labels, nil
+}
+
+var errNoQueryImage = errors.New("an uploaded image, s3Key, or imageData is required")
+var errImageTooLarge = errors.New("uploaded image exceeds the 5MB Rekognition limit")
+
+// resolveQueryImage - builds the Rekognition query image from either a multipart file
+// upload (field "image"), an S3 key, or an inline base64 image body, in that priority order
+func resolveQueryImage(r *http.Request) (*rekognition.Image, error) {
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		data, err := ioutil.ReadAll(io.LimitReader(file, maxVisualSearchImageBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxVisualSearchImageBytes {
+			return nil, errImageTooLarge
+		}
+		return &rekognition.Image{Bytes: data}, nil
+	}
+
+	var req visualSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(req.S3Key) > 0:
+		return &rekognition.Image{
+			S3Object: &rekognition.S3Object{
+				Bucket: aws.String(os.Getenv("IMAGE_S3_BUCKET")),
+				Name:   aws.String(req.S3Key),
+			},
+		}, nil
+	case len(req.ImageData) > 0:
+		data, err := base64.StdEncoding.DecodeString(req.ImageData)
+		if err != nil {
+			return nil, err
+		}
+		return &rekognition.Image{Bytes: data}, nil
+	default:
+		return nil, errNoQueryImage
+	}
+}
+
+// ProductSearchVisual Handler - POST /products/search/visual
+// Accepts a query image (a multipart file upload, an S3 key of an already-uploaded image,
+// or inline base64 image data), runs it through Rekognition DetectLabels, and ranks catalog
+// products by label overlap
+func ProductSearchVisual(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	image, err := resolveQueryImage(r)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusUnprocessableEntity)
+		return
+	}
+
+	queryLabels, err := detectLabelsForRekognitionImage(image)
+	if err != nil {
+		http.Error(w, "Unable to analyze query image", http.StatusInternalServerError)
+		return
+	}
+
+	candidates := RepoFindALLProducts()
+	ranked := rankByLabelSimilarity(queryLabels, candidates, similarityThreshold(r), similarityTopN(r))
+
+	if err := json.NewEncoder(w).Encode(ranked); err != nil {
+		panic(err)
+	}
+}
+
+// ProductSimilar Handler - GET /products/{id}/similar
+// Ranks catalog products by label overlap against the target product's own image labels
+func ProductSimilar(w http.ResponseWriter, r *http.Request) {
+	initResponse(&w)
+
+	vars := mux.Vars(r)
+
+	target := RepoFindProduct(vars["productID"])
+	if !target.Initialized() {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	// Exclude the target product from its own similarity results before ranking, not
+	// after: it always scores ~1.0 against itself, so truncating to topN first would
+	// leave a guaranteed-wasted slot and under-fill the response by one.
+	all := RepoFindALLProducts()
+	candidates := all[:0]
+	for _, candidate := range all {
+		if candidate.ID != target.ID {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	ranked := rankByLabelSimilarity(target.ImageLabels, candidates, similarityThreshold(r), similarityTopN(r))
+
+	if err := json.NewEncoder(w).Encode(ranked); err != nil {
+		panic(err)
+	}
+}